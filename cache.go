@@ -1,9 +1,11 @@
 package midstore
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -32,6 +34,14 @@ type Cache[T Type] struct {
 	h            IHandle[T]
 	writer       IWriter //刷新失败后执行失败回调失败的数据直接写入本地文件系统
 	log          ILog
+	observer     IObserver
+
+	dropped atomic.Uint64 //DropNewest/DropOldest 策略丢弃的条数
+	spilled atomic.Uint64 //SpillToDisk 策略落盘的条数
+	blocked atomic.Uint64 //BlockWithTimeout 策略等待超时放弃的条数
+
+	wal        *wal[T]       //预写日志，WithWAL 开启后非 nil
+	walLastSeq atomic.Uint64 //当前 c.data 里最后一条记录对应的 WAL 序列号，用于 flush 后 checkpoint
 }
 
 // FailedBackRows 回调失败的日志格式
@@ -67,12 +77,39 @@ func NewCache[T Type](h IHandle[T], opts ...Option) *Cache[T] {
 		opt.writer = &defaultWriter{opt: opt}
 	}
 
+	if opt.observer == nil {
+		opt.observer = NewNoopObserver()
+	}
+
 	defaultCap := opt.maxLength
 	if defaultCap > 300 {
 		// 5 * 60 每秒5条
 		defaultCap = 300
 	}
 
+	var recovered []T
+	var cacheWAL *wal[T]
+	if opt.walEnabled {
+		var codec Codec[T]
+		if c, ok := opt.codec.(Codec[T]); ok {
+			codec = c
+		}
+
+		w, rows, err := newWAL[T](opt.walDir, opt.walSyncEvery, opt.walMaxSegmentBytes, codec, opt.log)
+		if err != nil {
+			opt.log.Errorf("wal recover error: %v", err)
+		} else {
+			cacheWAL = w
+			recovered = rows
+			if len(recovered) > 0 {
+				opt.log.Infof("wal recovered %d rows", len(recovered))
+			}
+		}
+	}
+
+	data := make([]T, 0, defaultCap)
+	data = append(data, recovered...)
+
 	return &Cache[T]{
 		rw:           sync.RWMutex{},
 		wg:           sync.WaitGroup{},
@@ -82,30 +119,180 @@ func NewCache[T Type](h IHandle[T], opts ...Option) *Cache[T] {
 		cancel:       cancel,
 		options:      opt,
 		h:            h,
-		data:         make([]T, 0, defaultCap),
+		data:         data,
 		writer:       opt.writer,
 		log:          opt.log,
+		observer:     opt.observer,
+		wal:          cacheWAL,
+	}
+}
+
+// Add push data into Cache.data list tail . 返回值 accepted 为 0 或 1，
+// 只有配置了 WithHardCapacity 且触发背压时才会是 0 或非 nil error。
+func (c *Cache[T]) Add(row T) (accepted int, err error) {
+	return c.AddList([]T{row})
+}
+
+// AddList push data into Cache.data list tail . accepted 可能小于 len(rows)，
+// 取决于 WithHardCapacity/WithOverflowPolicy 的配置，accepted 为实际进入 Cache.data 的条数。
+func (c *Cache[T]) AddList(rows []T) (accepted int, err error) {
+	if len(rows) == 0 {
+		return 0, nil
 	}
+
+	if c.options.hardCapacity <= 0 {
+		c.rw.Lock()
+		for _, row := range rows {
+			c.walAppend(row)
+			c.data = append(c.data, row)
+		}
+		queueLen := len(c.data)
+		c.sendFlushSignalIfReachMaxLength()
+		c.rw.Unlock()
+		c.observer.OnAdd(c.ctx, queueLen)
+		return len(rows), nil
+	}
+
+	if c.options.overflowPolicy.kind == overflowBlockWithTimeout {
+		return c.addBlocking(rows)
+	}
+	return c.addWithOverflow(rows)
 }
 
-// Add push data into Cache.data list front .
-func (c *Cache[T]) Add(row T) {
+// addWithOverflow 处理 DropNewest/DropOldest/SpillToDisk 三种非阻塞策略
+func (c *Cache[T]) addWithOverflow(rows []T) (accepted int, err error) {
+	var toSpill []T
+
 	c.rw.Lock()
-	defer c.rw.Unlock()
-	c.data = append(c.data, row)
+
+	for _, row := range rows {
+		if len(c.data) < c.options.hardCapacity {
+			c.walAppend(row)
+			c.data = append(c.data, row)
+			accepted++
+			continue
+		}
+
+		switch c.options.overflowPolicy.kind {
+		case overflowDropOldest:
+			if len(c.data) > 0 {
+				c.data = c.data[1:]
+				c.dropped.Add(1)
+			}
+			c.walAppend(row)
+			c.data = append(c.data, row)
+			accepted++
+		case overflowSpillToDisk:
+			// 落盘是同步磁盘 I/O，先攒着，等下面释放 c.rw 之后再一次性写入，
+			// 不要在持有锁的时候做 I/O 阻塞其它 Add/Len 调用方
+			toSpill = append(toSpill, row)
+		default: // overflowDropNewest
+			c.dropped.Add(1)
+		}
+	}
+
 	c.sendFlushSignalIfReachMaxLength()
+	queueLen := len(c.data)
+	c.rw.Unlock()
+
+	if accepted > 0 {
+		c.observer.OnAdd(c.ctx, queueLen)
+	}
+
+	// 批量落盘，一次 Write 而不是每行一次系统调用
+	c.spillRows(toSpill)
+
+	return accepted, nil
+}
+
+// addBlocking 实现 BlockWithTimeout 策略：轮询等待 flush 腾出空间，超时放弃
+func (c *Cache[T]) addBlocking(rows []T) (accepted int, err error) {
+	deadline := time.Now().Add(c.options.overflowPolicy.blockTimeout)
+
+	for _, row := range rows {
+		for {
+			c.rw.Lock()
+			if len(c.data) < c.options.hardCapacity {
+				c.walAppend(row)
+				c.data = append(c.data, row)
+				queueLen := len(c.data)
+				c.sendFlushSignalIfReachMaxLength()
+				c.rw.Unlock()
+				c.observer.OnAdd(c.ctx, queueLen)
+				accepted++
+				break
+			}
+			c.rw.Unlock()
+
+			if time.Now().After(deadline) {
+				c.blocked.Add(uint64(len(rows) - accepted))
+				return accepted, fmt.Errorf("midstore: add blocked timeout after %s, capacity: %d", c.options.overflowPolicy.blockTimeout, c.options.hardCapacity)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	return accepted, nil
+}
+
+// walAppend 要求调用方已持有 c.rw 的写锁；WAL 写入失败只记日志不阻断内存缓存，
+// 代价是那一条数据在进程崩溃时可能丢失恢复能力，但不影响当次的刷新流程
+func (c *Cache[T]) walAppend(row T) {
+	if c.wal == nil {
+		return
+	}
+	seq, err := c.wal.append(row)
+	if err != nil {
+		c.log.Errorf("wal append error: %v", err)
+		return
+	}
+	c.walLastSeq.Store(seq)
+}
+
+// Stats 返回背压相关的运行状态计数
+func (c *Cache[T]) Stats() Stats {
+	return Stats{
+		Dropped: c.dropped.Load(),
+		Spilled: c.spilled.Load(),
+		Blocked: c.blocked.Load(),
+	}
 }
 
-// AddList push data into Cache.data list front .
-func (c *Cache[T]) AddList(rows []T) {
+// spillRows 把超出 HardCapacity 的数据直接落盘，不经过 FlushCall/FailedCall
+func (c *Cache[T]) spillRows(rows []T) {
 	if len(rows) == 0 {
 		return
 	}
 
-	c.rw.Lock()
-	defer c.rw.Unlock()
-	c.data = append(c.data, rows...)
-	c.sendFlushSignalIfReachMaxLength()
+	if codec, ok := c.options.codec.(Codec[T]); ok && codec != nil {
+		var buf bytes.Buffer
+		meta := Meta{Time: time.Now().Format(time.RFC3339), Count: len(rows)}
+		if err := codec.Encode(&buf, rows, meta); err != nil {
+			c.log.Errorf("spillRows codec.Encode error, err: %v", err)
+			return
+		}
+		err := c.writer.Write(buf.Bytes())
+		c.observer.OnDiskWrite(c.ctx, buf.Len(), err)
+		if err != nil {
+			c.log.Errorf("spillRows writer.Write error, err: %v", err)
+			return
+		}
+	} else {
+		backData := FailedBackRows[T]{Time: time.Now().Format(time.RFC3339), Data: rows}
+		body, err := json.Marshal(backData)
+		if err != nil {
+			c.log.Errorf("spillRows json.Marshal error, err: %v", err)
+			return
+		}
+		err = c.writer.Write(body)
+		c.observer.OnDiskWrite(c.ctx, len(body), err)
+		if err != nil {
+			c.log.Errorf("spillRows writer.Write error, err: %v", err)
+			return
+		}
+	}
+
+	c.spilled.Add(uint64(len(rows)))
 }
 
 // Len returns the Cache.data element length .
@@ -129,6 +316,9 @@ func (c *Cache[T]) Stop() {
 	c.cancel()
 	c.wg.Wait()
 	_ = c.writer.Close()
+	if c.wal != nil {
+		_ = c.wal.close()
+	}
 
 	if c.flushChannel != nil {
 		close(c.flushChannel)
@@ -180,97 +370,289 @@ func (c *Cache[T]) run() {
 	}
 }
 
+// flush 把 c.data 挪到本地变量后立即释放写锁，重试/FailedCall/落盘都对着这份本地
+// 拷贝操作，这样 Add/AddList 不会在整个 FlushCall（含重试退避等待）期间被阻塞。
 func (c *Cache[T]) flush() {
-	c.rw.Lock()
-	defer c.rw.Unlock()
 	if c.h == nil {
 		return
 	}
 
+	c.rw.Lock()
 	total := len(c.data)
 	if total == 0 {
+		c.rw.Unlock()
 		return
 	}
 
-	defer func() {
-		c.data = c.data[:0]
-	}()
+	batch := make([]T, total)
+	copy(batch, c.data)
+	c.data = c.data[:0]
+	checkpointSeq := c.walLastSeq.Load()
+	c.rw.Unlock()
 
 	c.log.Debugf("开始刷新数据，共 %d 条", total)
 
-	var err error
-	//刷新数据
-	if err = c.h.FlushCall(c.data); err == nil {
-		c.log.Infof("FlushCall success list total: %d", total)
+	start := time.Now()
+	ok, flushErr := c.flushWithRetry(batch)
+	c.observer.OnBatchFlushed(c.ctx, total, time.Since(start), flushErr)
+	if ok {
+		c.walCheckpoint(checkpointSeq)
 		return
-	} else {
-		c.log.Errorf("FlushCall error list total: %d, error: %v", total, err)
 	}
 
-	if err = c.h.FailedCall(c.data); err == nil {
+	err := c.h.FailedCall(batch)
+	c.observer.OnFailedCall(c.ctx, total, err)
+	if err == nil {
 		c.log.Infof("FailedCall success list total: %d", total)
+		c.walCheckpoint(checkpointSeq)
 		return
-	} else {
-		c.log.Errorf("FailedCall error list total: %d, error: %v", total, err)
 	}
+	c.log.Errorf("FailedCall error list total: %d, error: %v", total, err)
 
-	c.failedCallBack(c.data)
+	if c.failedCallBack(batch) {
+		c.walCheckpoint(checkpointSeq)
+	}
 }
 
-func (c *Cache[T]) failedCallBack(rows []T) {
-	if !c.options.enableLocalBackup || len(rows) == 0 {
+// walCheckpoint 把 WAL 的确认位置推进到 seq，只应该在 seq 之前的数据已经被确认
+// durable 处理之后调用（FlushCall/重试成功、Classifier 判定 Drop，或者 FailedCall/
+// 失败落盘确认写入成功），否则崩溃恢复时会丢失一批既没有落地也无法从 WAL 重放的数据。
+func (c *Cache[T]) walCheckpoint(seq uint64) {
+	if c.wal == nil {
 		return
 	}
-	file, err := c.writer.GetWriter()
-	if err != nil {
-		c.log.Errorf("getFailedFile error, data: %v, err: %v", rows, err)
-		return
+	if err := c.wal.checkpoint(seq); err != nil {
+		c.log.Errorf("wal checkpoint error: %v", err)
+	}
+}
+
+// flushWithRetry 按 WithRetry 配置的退避策略重试 FlushCall，ok==true 表示数据已经被
+// 处理完毕（成功，或被 Classifier 判定为 Drop），不需要再走 FailedCall/本地落盘；
+// ok==false 表示重试耗尽或被判定为 DeadLetter，交给调用方继续走 FailedCall/落盘，
+// lastErr 是最后一次 FlushCall 返回的错误（ok==true 时为 nil），用于上报给 IObserver。
+func (c *Cache[T]) flushWithRetry(batch []T) (ok bool, lastErr error) {
+	maxAttempts := c.options.retryMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	classifier, _ := c.h.(Classifier[T])
+	backoff := c.options.retryInitial
+	if c.options.retryMax > 0 && backoff > c.options.retryMax {
+		backoff = c.options.retryMax
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err := c.h.FlushCall(batch)
+		if err == nil {
+			c.log.Infof("FlushCall success list total: %d", len(batch))
+			return true, nil
+		}
+		c.log.Errorf("FlushCall error list total: %d, attempt: %d/%d, error: %v", len(batch), attempt, maxAttempts, err)
+		lastErr = err
+
+		if classifier != nil {
+			switch classifier.Classify(err) {
+			case RetryDecisionDrop:
+				c.log.Warnf("FlushCall classified as drop, discarding %d rows", len(batch))
+				return true, nil
+			case RetryDecisionDeadLetter:
+				return false, lastErr
+			}
+		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		time.Sleep(withJitter(backoff, c.options.retryJitter))
+		backoff *= 2
+		if c.options.retryMax > 0 && backoff > c.options.retryMax {
+			backoff = c.options.retryMax
+		}
+	}
+
+	return false, lastErr
+}
+
+// withJitter 在 d 的基础上叠加 ±jitter 比例的随机抖动，jitter<=0 时原样返回 d
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	delta := float64(d) * jitter
+	offset := (rand.Float64()*2 - 1) * delta
+	result := float64(d) + offset
+	if result < 0 {
+		result = 0
+	}
+	return time.Duration(result)
+}
+
+// failedCallBack 把 FailedCall 处理失败之后的数据落盘，返回 rows 是否被完整、确认地
+// 写入磁盘——只有返回 true 时调用方才能认为这批数据已经 durable，可以推进 WAL checkpoint。
+func (c *Cache[T]) failedCallBack(rows []T) bool {
+	if !c.options.enableLocalBackup || len(rows) == 0 {
+		return false
+	}
+
+	if codec, ok := c.options.codec.(Codec[T]); ok && codec != nil {
+		return c.saveBackWithCodec(codec, rows)
 	}
 
-	w := bufio.NewWriter(file)
 	if c.options.failedBackRows {
-		c.saveBackRows(w, rows)
-	} else {
-		c.saveBackRow(w, rows)
+		return c.saveBackRows(rows)
 	}
+	return c.saveBackRow(rows)
+}
 
-	return
+func (c *Cache[T]) saveBackWithCodec(codec Codec[T], rows []T) bool {
+	var buf bytes.Buffer
+	meta := Meta{Time: time.Now().Format(time.RFC3339), Count: len(rows)}
+	if err := codec.Encode(&buf, rows, meta); err != nil {
+		c.log.Errorf("failedCallBack codec.Encode error, err: %v", err)
+		return false
+	}
+	err := c.writer.Write(buf.Bytes())
+	c.observer.OnDiskWrite(c.ctx, buf.Len(), err)
+	if err != nil {
+		c.log.Errorf("failedCallBack writer.Write error, err: %v", err)
+		return false
+	}
+	return true
 }
 
-func (c *Cache[T]) saveBackRows(w *bufio.Writer, rows []T) {
+func (c *Cache[T]) saveBackRows(rows []T) bool {
 	backData := FailedBackRows[T]{
 		Time: time.Now().Format(time.RFC3339),
 		Data: rows,
 	}
-	body, _ := json.Marshal(backData)
-	strBody := string(body)
-	if _, err := w.Write(body); err != nil {
-		c.log.Errorf("failedCallBack w.Write body error,body: %s, err: %v", strBody, err)
-		return
+	body, err := json.Marshal(backData)
+	if err != nil {
+		c.log.Errorf("failedCallBack json.Marshal error, data: %v, err: %v", rows, err)
+		return false
 	}
-	_, _ = w.Write([]byte("\n"))
-
-	if err := w.Flush(); err != nil {
-		c.log.Errorf("failedCallBack w.Flush error,body: %v：%v", strBody, err)
+	err = c.writer.Write(body)
+	c.observer.OnDiskWrite(c.ctx, len(body), err)
+	if err != nil {
+		c.log.Errorf("failedCallBack writer.Write error, body: %s, err: %v", string(body), err)
+		return false
 	}
+	return true
 }
 
-func (c *Cache[T]) saveBackRow(w *bufio.Writer, rows []T) {
+// saveBackRow 一批一行地落盘，返回是否所有行都确认写入成功；只要有一行失败就返回 false，
+// 调用方据此跳过 WAL checkpoint，让这批数据（包括已经写成功的行）留在 WAL 里等待下次重放。
+func (c *Cache[T]) saveBackRow(rows []T) bool {
 	now := time.Now().Format(time.RFC3339)
+	ok := true
 	for _, row := range rows {
 		item := FailedBackRow[T]{
 			Time: now,
 			Data: row,
 		}
-		body, _ := json.Marshal(item)
-		if _, err := w.Write(body); err != nil {
-			c.log.Errorf("failedCallBack w.Write body error,body: %s, err: %v", string(body), err)
+		body, err := json.Marshal(item)
+		if err != nil {
+			c.log.Errorf("failedCallBack json.Marshal error, row: %v, err: %v", row, err)
+			ok = false
 			continue
 		}
-		_, _ = w.Write([]byte("\n"))
+		err = c.writer.Write(body)
+		c.observer.OnDiskWrite(c.ctx, len(body), err)
+		if err != nil {
+			c.log.Errorf("failedCallBack writer.Write error, body: %s, err: %v", string(body), err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// ReplayFailed 要求 Options.writer 实现 IReplayWriter（例如 NewDiskQueueWriter），
+// 按 batchSize 把落盘的失败数据重新组装成 []T 吐出，供调用方重新走一遍 FlushCall。
+// 返回的 channel 会在 ctx 被取消或 ReadChan 被关闭后关闭；DiskQueueWriter 的 ReadChan
+// 不会关闭，所以读完积压数据后 channel 保持打开，直到 ctx 被取消。
+func (c *Cache[T]) ReplayFailed(ctx context.Context, batchSize int) (<-chan []T, error) {
+	replayer, ok := c.writer.(IReplayWriter)
+	if !ok {
+		return nil, fmt.Errorf("writer %T does not support replay", c.writer)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultMaxLength
+	}
+
+	out := make(chan []T)
+
+	go func() {
+		defer close(out)
+
+		batch := make([]T, 0, batchSize)
+		// flushBatch 把当前攒够的 batch 发给 out；final==true 表示这是 goroutine 退出前
+		// 最后一次发送（ctx 已经 Done 或者 ReadChan 已经关闭），这种情况下不能再用
+		// select ctx.Done() 去竞争同一个已经就绪的 case，否则 Go 会在两个就绪分支之间
+		// 伪随机选择，导致最后一批数据被静默丢弃。final==false 时维持原来的行为：
+		// ctx 被取消就放弃这次发送，避免在消费方已经不读 out 的情况下永久阻塞。
+		flushBatch := func(final bool) {
+			if len(batch) == 0 {
+				return
+			}
+			if final {
+				out <- batch
+			} else {
+				select {
+				case out <- batch:
+				case <-ctx.Done():
+				}
+			}
+			batch = make([]T, 0, batchSize)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				flushBatch(true)
+				return
+			case frame, ok := <-replayer.ReadChan():
+				if !ok {
+					flushBatch(true)
+					return
+				}
+
+				decoded, err := c.decodeFrame(frame)
+				if err != nil {
+					c.log.Errorf("ReplayFailed decode frame error, err: %v", err)
+					continue
+				}
+				batch = append(batch, decoded...)
+
+				if len(batch) >= batchSize {
+					flushBatch(false)
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// decodeFrame 把 IReplayWriter 吐出的一帧原始数据还原成 []T，优先使用 Options.codec，
+// 没有配置 codec 时按落盘时的默认 JSON 格式解析（与 saveBackRows/saveBackRow 对称）
+func (c *Cache[T]) decodeFrame(frame []byte) ([]T, error) {
+	if codec, ok := c.options.codec.(Codec[T]); ok && codec != nil {
+		rows, _, err := codec.Decode(bytes.NewReader(frame))
+		return rows, err
+	}
+
+	if c.options.failedBackRows {
+		var rows FailedBackRows[T]
+		if err := json.Unmarshal(frame, &rows); err != nil {
+			return nil, err
+		}
+		return rows.Data, nil
 	}
 
-	if err := w.Flush(); err != nil {
-		c.log.Errorf("failedCallBack w.Flush error,rows: %v：%v", rows, err)
+	var row FailedBackRow[T]
+	if err := json.Unmarshal(frame, &row); err != nil {
+		return nil, err
 	}
+	return []T{row.Data}, nil
 }
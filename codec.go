@@ -0,0 +1,255 @@
+package midstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"reflect"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Meta 描述一批失败数据编码时附带的元信息
+type Meta struct {
+	Time  string //编码时间，RFC3339
+	Count int    //本批数据条数
+}
+
+// Codec 负责把一批失败数据编码写入 IWriter，以及从 IReplayWriter 吐出的原始帧解码回 []T。
+// 编码/解码必须对称，否则 Cache.ReplayFailed 无法正确回放。未配置 Codec 时 Cache 使用
+// FailedBackRows/FailedBackRow 的 json.Marshal 格式；JSONLinesCodec 提供 JSON-Lines 格式，
+// 高吞吐场景可以换成 ProtoCodec 或在外层套一层压缩。
+type Codec[T Type] interface {
+	Encode(w io.Writer, batch []T, meta Meta) error
+	Decode(r io.Reader) ([]T, Meta, error)
+}
+
+// JSONLinesCodec 每行一个 JSON 对象，第一行是 Meta，之后每行是一条数据
+type JSONLinesCodec[T Type] struct{}
+
+// NewJSONLinesCodec 创建一个 JSON-Lines 编解码器
+func NewJSONLinesCodec[T Type]() *JSONLinesCodec[T] {
+	return &JSONLinesCodec[T]{}
+}
+
+var _ Codec[Type] = &JSONLinesCodec[Type]{}
+
+func (*JSONLinesCodec[T]) Encode(w io.Writer, batch []T, meta Meta) error {
+	bw := bufio.NewWriter(w)
+
+	metaLine, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if _, err = bw.Write(metaLine); err != nil {
+		return err
+	}
+	if err = bw.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	for _, row := range batch {
+		body, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if _, err = bw.Write(body); err != nil {
+			return err
+		}
+		if err = bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (*JSONLinesCodec[T]) Decode(r io.Reader) ([]T, Meta, error) {
+	var meta Meta
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		return nil, meta, io.EOF
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+		return nil, meta, err
+	}
+
+	rows := make([]T, 0, meta.Count)
+	for scanner.Scan() {
+		var row T
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, meta, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, meta, scanner.Err()
+}
+
+// ProtoType 是支持二进制反序列化的 Type，ProtoCodec 要求数据类型实现该接口，
+// 因为 Type 本身只约定了 Marshal，没有对称的 Unmarshal
+type ProtoType interface {
+	Type
+	Unmarshal(data []byte) error
+}
+
+// ProtoCodec 以「4 字节大端长度 + 原始二进制」逐条帧写入，复用 T 已有的 Marshal()，
+// 相比 JSON 省去了字段名和引号的开销，适合高吞吐、已经有 protobuf Marshal 的 Type
+type ProtoCodec[T ProtoType] struct{}
+
+// NewProtoCodec 创建一个基于 T.Marshal()/T.Unmarshal() 的二进制编解码器
+func NewProtoCodec[T ProtoType]() *ProtoCodec[T] {
+	return &ProtoCodec[T]{}
+}
+
+func (*ProtoCodec[T]) Encode(w io.Writer, batch []T, meta Meta) error {
+	metaBody, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err = writeFrame(w, metaBody); err != nil {
+		return err
+	}
+
+	for _, row := range batch {
+		body, err := row.Marshal()
+		if err != nil {
+			return err
+		}
+		if err = writeFrame(w, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newProtoRow 返回一个可以安全调用 Unmarshal 的 T 实例。Unmarshal 通常要求指针接收者
+// （才能把反序列化结果写回去），这意味着满足 ProtoType 约束的 T 本身往往就是指针类型，
+// 此时 var row T 得到的是 nil 指针，直接调用 row.Unmarshal 会操作空指针。这里用反射
+// 分配一个指向零值的新实例，再转回 T；T 是非指针类型时 reflect.TypeOf(row) 判断为
+// 非指针，直接返回 var row T 的零值，和原来的行为一致。
+func newProtoRow[T ProtoType]() T {
+	var row T
+	if t := reflect.TypeOf(row); t != nil && t.Kind() == reflect.Ptr {
+		return reflect.New(t.Elem()).Interface().(T)
+	}
+	return row
+}
+
+func (*ProtoCodec[T]) Decode(r io.Reader) ([]T, Meta, error) {
+	var meta Meta
+
+	metaBody, err := readFrame(r)
+	if err != nil {
+		return nil, meta, err
+	}
+	if err = json.Unmarshal(metaBody, &meta); err != nil {
+		return nil, meta, err
+	}
+
+	rows := make([]T, 0, meta.Count)
+	for {
+		body, err := readFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, meta, err
+		}
+
+		row := newProtoRow[T]()
+		if err = row.Unmarshal(body); err != nil {
+			return nil, meta, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, meta, nil
+}
+
+func writeFrame(w io.Writer, data []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(lenBuf[:])
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// gzipCodec 在任意内层 Codec 外面包一层 gzip 压缩
+type gzipCodec[T Type] struct {
+	inner Codec[T]
+}
+
+// NewGzipCodec 包装 inner，编码时先走 inner 再整体 gzip 压缩，解码时先解压再走 inner
+func NewGzipCodec[T Type](inner Codec[T]) Codec[T] {
+	return &gzipCodec[T]{inner: inner}
+}
+
+func (g *gzipCodec[T]) Encode(w io.Writer, batch []T, meta Meta) error {
+	gw := gzip.NewWriter(w)
+	if err := g.inner.Encode(gw, batch, meta); err != nil {
+		_ = gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+func (g *gzipCodec[T]) Decode(r io.Reader) ([]T, Meta, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	defer gr.Close()
+	return g.inner.Decode(gr)
+}
+
+// zstdCodec 在任意内层 Codec 外面包一层 zstd 压缩，吞吐场景下比 gzip 更省 CPU
+type zstdCodec[T Type] struct {
+	inner Codec[T]
+}
+
+// NewZstdCodec 包装 inner，编码时先走 inner 再整体 zstd 压缩，解码时先解压再走 inner
+func NewZstdCodec[T Type](inner Codec[T]) Codec[T] {
+	return &zstdCodec[T]{inner: inner}
+}
+
+func (z *zstdCodec[T]) Encode(w io.Writer, batch []T, meta Meta) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	if err = z.inner.Encode(zw, batch, meta); err != nil {
+		_ = zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func (z *zstdCodec[T]) Decode(r io.Reader) ([]T, Meta, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	defer zr.Close()
+	return z.inner.Decode(zr)
+}
@@ -0,0 +1,106 @@
+package midstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// protoElem 是一个实现了 ProtoType 的测试用类型，Marshal/Unmarshal 底层借用 JSON，
+// 只是为了验证 ProtoCodec 的帧格式，不代表真实的二进制协议
+type protoElem struct {
+	Id   int
+	Name string
+}
+
+func (p protoElem) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func (p *protoElem) Unmarshal(data []byte) error {
+	return json.Unmarshal(data, p)
+}
+
+func codecRoundTrip[T Type](t *testing.T, codec Codec[T], rows []T) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	meta := Meta{Time: "2026-07-26T00:00:00Z", Count: len(rows)}
+	if err := codec.Encode(&buf, rows, meta); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+
+	got, gotMeta, err := codec.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if gotMeta != meta {
+		t.Fatalf("meta mismatch: want %+v, got %+v", meta, gotMeta)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Fatalf("rows mismatch: want %+v, got %+v", rows, got)
+	}
+}
+
+func TestJSONLinesCodecRoundTrip(t *testing.T) {
+	codecRoundTrip[elem](t, NewJSONLinesCodec[elem](), []elem{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}})
+}
+
+func TestJSONLinesCodecEmptyBatch(t *testing.T) {
+	codecRoundTrip[elem](t, NewJSONLinesCodec[elem](), []elem{})
+}
+
+func TestProtoCodecRoundTrip(t *testing.T) {
+	rows := []*protoElem{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}}
+
+	var buf bytes.Buffer
+	codec := NewProtoCodec[*protoElem]()
+	meta := Meta{Time: "2026-07-26T00:00:00Z", Count: len(rows)}
+	if err := codec.Encode(&buf, rows, meta); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+
+	got, gotMeta, err := codec.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if gotMeta != meta {
+		t.Fatalf("meta mismatch: want %+v, got %+v", meta, gotMeta)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("expected %d rows, got %d", len(rows), len(got))
+	}
+	for i := range rows {
+		if *got[i] != *rows[i] {
+			t.Fatalf("row %d mismatch: want %+v, got %+v", i, *rows[i], *got[i])
+		}
+	}
+}
+
+func TestGzipCodecRoundTrip(t *testing.T) {
+	codecRoundTrip[elem](t, NewGzipCodec[elem](NewJSONLinesCodec[elem]()), []elem{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}})
+}
+
+func TestGzipCodecActuallyCompresses(t *testing.T) {
+	rows := make([]elem, 0, 200)
+	for i := 0; i < 200; i++ {
+		rows = append(rows, elem{Id: i, Name: "the quick brown fox jumps over the lazy dog"})
+	}
+
+	var plain, gzipped bytes.Buffer
+	meta := Meta{Time: "2026-07-26T00:00:00Z", Count: len(rows)}
+	if err := NewJSONLinesCodec[elem]().Encode(&plain, rows, meta); err != nil {
+		t.Fatalf("plain Encode error: %v", err)
+	}
+	if err := NewGzipCodec[elem](NewJSONLinesCodec[elem]()).Encode(&gzipped, rows, meta); err != nil {
+		t.Fatalf("gzip Encode error: %v", err)
+	}
+	if gzipped.Len() >= plain.Len() {
+		t.Fatalf("expected gzip output (%d bytes) to be smaller than plain output (%d bytes)", gzipped.Len(), plain.Len())
+	}
+}
+
+func TestZstdCodecRoundTrip(t *testing.T) {
+	codecRoundTrip[elem](t, NewZstdCodec[elem](NewJSONLinesCodec[elem]()), []elem{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}})
+}
@@ -0,0 +1,372 @@
+package midstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DiskQueueWriter 是一个本地磁盘队列实现的 IWriter，参考了 nsq/diskqueue 的设计：
+// 数据按 maxBytesPerFile 分段存储，每条数据前置 4 字节大端长度，读写位置记录在
+// 独立的 meta 文件中，支持按 syncEvery/syncTimeout 周期性 fsync。
+// 相比 defaultWriter 的“按天滚动的 .log 文件”，它天然支持顺序回放（ReadChan），
+// 可以把下游恢复后积压的失败数据重新吐出来重试。
+type DiskQueueWriter struct {
+	mu sync.Mutex
+
+	dir  string
+	name string
+
+	maxBytesPerFile int64
+	syncEvery       int64
+	syncTimeout     time.Duration
+
+	writeFile    *os.File
+	writeFileNum int64
+	writePos     int64
+
+	readFileNum int64
+	readPos     int64
+	depth       int64
+
+	writesSinceSync int64
+
+	readChan chan []byte
+	exitChan chan struct{}
+	exitOnce sync.Once
+	wg       sync.WaitGroup
+
+	log ILog
+}
+
+var _ IReplayWriter = &DiskQueueWriter{}
+
+// NewDiskQueueWriter 创建一个磁盘队列，dir 是数据目录，name 是队列名（用于拼文件名前缀），
+// maxBytesPerFile 控制单个分段文件的大小上限，syncEvery/syncTimeout 控制 fsync 的频率
+// （达到写入次数或超过时间间隔任一条件都会触发一次 fsync + 元数据落盘）。
+func NewDiskQueueWriter(dir, name string, maxBytesPerFile int64, syncEvery int64, syncTimeout time.Duration) (*DiskQueueWriter, error) {
+	return newDiskQueueWriter(dir, name, maxBytesPerFile, syncEvery, syncTimeout, newLog())
+}
+
+// NewDiskQueueWriterWithLog 同 NewDiskQueueWriter，允许传入自定义日志实现
+func NewDiskQueueWriterWithLog(dir, name string, maxBytesPerFile int64, syncEvery int64, syncTimeout time.Duration, log ILog) (*DiskQueueWriter, error) {
+	return newDiskQueueWriter(dir, name, maxBytesPerFile, syncEvery, syncTimeout, log)
+}
+
+func newDiskQueueWriter(dir, name string, maxBytesPerFile int64, syncEvery int64, syncTimeout time.Duration, log ILog) (*DiskQueueWriter, error) {
+	if maxBytesPerFile <= 0 {
+		maxBytesPerFile = 100 * 1024 * 1024
+	}
+	if syncEvery <= 0 {
+		syncEvery = 1000
+	}
+	if syncTimeout <= 0 {
+		syncTimeout = time.Second
+	}
+	if log == nil {
+		log = newLog()
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &DiskQueueWriter{
+		dir:             dir,
+		name:            name,
+		maxBytesPerFile: maxBytesPerFile,
+		syncEvery:       syncEvery,
+		syncTimeout:     syncTimeout,
+		readChan:        make(chan []byte),
+		exitChan:        make(chan struct{}),
+		log:             log,
+	}
+
+	if err := d.loadMeta(); err != nil {
+		return nil, err
+	}
+
+	d.wg.Add(1)
+	go d.ioLoop()
+
+	return d, nil
+}
+
+func (d *DiskQueueWriter) fileName(fileNum int64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%s.diskqueue.%06d.dat", d.name, fileNum))
+}
+
+func (d *DiskQueueWriter) metaFileName() string {
+	return filepath.Join(d.dir, fmt.Sprintf("%s.diskqueue.meta.dat", d.name))
+}
+
+func (d *DiskQueueWriter) loadMeta() error {
+	f, err := os.Open(d.metaFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fscanf(f, "%d\n%d\n%d\n%d\n%d\n",
+		&d.depth, &d.readFileNum, &d.readPos, &d.writeFileNum, &d.writePos)
+	return err
+}
+
+// persistMeta 要求调用方已持有 mu
+func (d *DiskQueueWriter) persistMeta() error {
+	tmpName := d.metaFileName() + ".tmp"
+	f, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "%d\n%d\n%d\n%d\n%d\n",
+		d.depth, d.readFileNum, d.readPos, d.writeFileNum, d.writePos)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, d.metaFileName())
+}
+
+// Write 把 data 以「4 字节大端长度 + 原始数据」的格式追加到当前写入段，
+// 达到 syncEvery 或超过 syncTimeout 未同步时触发一次 fsync + 元数据落盘
+func (d *DiskQueueWriter) Write(data []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.writeFile == nil {
+		f, err := os.OpenFile(d.fileName(d.writeFileNum), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		d.writeFile = f
+		if d.writePos > 0 {
+			if _, err = d.writeFile.Seek(d.writePos, io.SeekStart); err != nil {
+				_ = d.writeFile.Close()
+				d.writeFile = nil
+				return err
+			}
+		}
+	}
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+
+	if _, err := d.writeFile.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	if _, err := d.writeFile.Write(data); err != nil {
+		return err
+	}
+
+	d.writePos += int64(len(lenBuf)) + int64(len(data))
+	d.depth++
+	d.writesSinceSync++
+
+	if d.writePos >= d.maxBytesPerFile {
+		if err := d.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if d.writesSinceSync >= d.syncEvery {
+		return d.sync()
+	}
+	return nil
+}
+
+// rotate 要求调用方已持有 mu
+func (d *DiskQueueWriter) rotate() error {
+	if err := d.writeFile.Close(); err != nil {
+		return err
+	}
+	d.writeFile = nil
+	d.writeFileNum++
+	d.writePos = 0
+	return nil
+}
+
+// sync 要求调用方已持有 mu
+func (d *DiskQueueWriter) sync() error {
+	if d.writeFile != nil {
+		if err := d.writeFile.Sync(); err != nil {
+			return err
+		}
+	}
+	d.writesSinceSync = 0
+	return d.persistMeta()
+}
+
+// Depth 返回尚未被读取消费的条目数
+func (d *DiskQueueWriter) Depth() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.depth
+}
+
+// ReadChan 返回一个只读 channel，按写入顺序吐出原始帧数据；
+// 读到的数据视为已消费，读取位置会被持久化，不支持重复消费
+func (d *DiskQueueWriter) ReadChan() <-chan []byte {
+	return d.readChan
+}
+
+func (d *DiskQueueWriter) ioLoop() {
+	defer d.wg.Done()
+
+	syncTicker := time.NewTicker(d.syncTimeout)
+	defer syncTicker.Stop()
+
+	var reader *bufio.Reader
+	var readFile *os.File
+
+	closeReadFile := func() {
+		if readFile != nil {
+			_ = readFile.Close()
+			readFile = nil
+			reader = nil
+		}
+	}
+	defer closeReadFile()
+
+	for {
+		d.mu.Lock()
+		hasData := d.readFileNum < d.writeFileNum || d.readPos < d.writePos
+		d.mu.Unlock()
+
+		if !hasData {
+			select {
+			case <-syncTicker.C:
+				d.mu.Lock()
+				if d.writesSinceSync > 0 {
+					_ = d.sync()
+				}
+				d.mu.Unlock()
+			case <-d.exitChan:
+				return
+			}
+			continue
+		}
+
+		d.mu.Lock()
+		if readFile == nil {
+			f, err := os.Open(d.fileName(d.readFileNum))
+			if err != nil {
+				d.log.Errorf("diskqueue open read file error: %v", err)
+				if d.readFileNum < d.writeFileNum {
+					// 落后的分段文件已经不存在（比如消费完毕删除后、meta 还没来得及
+					// 持久化就发生了重启），不是当前正在写入的分段，跳过继续读下一段，
+					// 避免 ioLoop 因为一个读不到的历史分段永久退出
+					d.readFileNum++
+					d.readPos = 0
+					_ = d.persistMeta()
+					d.mu.Unlock()
+					continue
+				}
+				// 当前就是正在写入的分段，大概率是权限/磁盘之类的暂时性错误，
+				// 等下一轮再试，不把 ioLoop 杀死
+				d.mu.Unlock()
+				select {
+				case <-time.After(d.syncTimeout):
+				case <-d.exitChan:
+					return
+				}
+				continue
+			}
+			if _, err = f.Seek(d.readPos, io.SeekStart); err != nil {
+				d.log.Errorf("diskqueue seek read file error: %v", err)
+				_ = f.Close()
+				d.mu.Unlock()
+				return
+			}
+			readFile = f
+			reader = bufio.NewReader(f)
+		}
+		curReadFileNum := d.readFileNum
+		d.mu.Unlock()
+
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(reader, lenBuf[:]); err != nil {
+			if err == io.EOF {
+				// 当前段已读完，切到下一段；删除分段文件的同时把读取位置落盘，
+				// 否则在 writesSinceSync 一直为 0（纯消费、没有新写入）的场景下
+				// meta 永远不会更新，崩溃重启后会打开一个已经被删除的分段
+				closeReadFile()
+				d.mu.Lock()
+				d.readFileNum++
+				d.readPos = 0
+				_ = os.Remove(d.fileName(curReadFileNum))
+				if err := d.persistMeta(); err != nil {
+					d.log.Errorf("diskqueue persist meta error: %v", err)
+				}
+				d.mu.Unlock()
+				continue
+			}
+			d.log.Errorf("diskqueue read length error: %v", err)
+			return
+		}
+
+		size := binary.BigEndian.Uint32(lenBuf[:])
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			d.log.Errorf("diskqueue read body error: %v", err)
+			return
+		}
+
+		// 等待消费方取走数据期间也要响应 syncTimeout，否则积压未被读取时定时 fsync 永远不会发生
+		for sent := false; !sent; {
+			select {
+			case d.readChan <- buf:
+				sent = true
+			case <-syncTicker.C:
+				d.mu.Lock()
+				if d.writesSinceSync > 0 {
+					_ = d.sync()
+				}
+				d.mu.Unlock()
+			case <-d.exitChan:
+				return
+			}
+		}
+
+		d.mu.Lock()
+		d.readPos += int64(len(lenBuf)) + int64(size)
+		d.depth--
+		d.mu.Unlock()
+	}
+}
+
+// Close 停止后台读循环并刷盘，关闭底层文件
+func (d *DiskQueueWriter) Close() error {
+	d.exitOnce.Do(func() {
+		close(d.exitChan)
+	})
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.writeFile != nil {
+		_ = d.writeFile.Sync()
+		_ = d.writeFile.Close()
+		d.writeFile = nil
+	}
+	return d.persistMeta()
+}
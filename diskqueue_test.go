@@ -0,0 +1,118 @@
+package midstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDiskQueueWriterRotateAndCatchUp 写入足够多的数据触发分段滚动，在没有新写入的情况下
+// （syncEvery 永远不会被触发）把 ReadChan 读空，验证消费完的分段文件会被删除、
+// 且读取位置在纯消费场景下也会被持久化——否则重启后 ioLoop 会尝试打开一个已经被
+// 删除的分段而永久退出（见 #chunk0-1 review fix）。
+func TestDiskQueueWriterRotateAndCatchUp(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := NewDiskQueueWriter(dir, "test", 64, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskQueueWriter error: %v", err)
+	}
+	defer q.Close()
+
+	const n = 20
+	payload := []byte("0123456789")
+	for i := 0; i < n; i++ {
+		if err := q.Write(payload); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-q.ReadChan():
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for entry %d", i)
+		}
+	}
+
+	// 读空之后没有新写入，writesSinceSync 一直是 0，已消费的分段只能靠 EOF 分支落盘
+	deadline := time.Now().Add(time.Second)
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir error: %v", err)
+		}
+		dataFiles := 0
+		for _, e := range entries {
+			if filepath.Ext(e.Name()) == ".dat" && e.Name() != "test.diskqueue.meta.dat" {
+				dataFiles++
+			}
+		}
+		if dataFiles <= 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("consumed segments were not cleaned up, got %d data files", dataFiles)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	q2, err := NewDiskQueueWriter(dir, "test", 64, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("re-open NewDiskQueueWriter error: %v", err)
+	}
+	defer q2.Close()
+
+	if depth := q2.Depth(); depth != 0 {
+		t.Fatalf("expected depth 0 after restart, meta was not caught up: got %d", depth)
+	}
+}
+
+// TestDiskQueueWriterSkipsMissingSegment 模拟一个已经被消费但 meta 尚未落盘的分段文件
+// 在重启前被删除（比如上一次进程退出得不够优雅），验证 ioLoop 发现分段打不开时会跳到
+// 下一个分段继续消费，而不是直接退出导致 ReadChan 永久不再产出数据（见 #chunk0-1 review fix）。
+func TestDiskQueueWriterSkipsMissingSegment(t *testing.T) {
+	dir := t.TempDir()
+
+	// maxBytesPerFile 故意设得很小，让每条数据各自独占一个分段
+	q, err := NewDiskQueueWriter(dir, "test", 6, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskQueueWriter error: %v", err)
+	}
+	if err := q.Write([]byte("stale")); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if err := q.Write([]byte("fresh")); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	// 没有消费者读取 ReadChan，ioLoop 会阻塞在投递第一条数据上，读取位置不会推进，
+	// 模拟「消费进度已经落后于实际删除」之前的状态
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// 模拟分段 0 在上一次运行里已经被当作消费完删除了，但 meta 还停留在分段 0 的起点
+	if err := os.Remove(filepath.Join(dir, "test.diskqueue.000000.dat")); err != nil {
+		t.Fatalf("remove segment error: %v", err)
+	}
+
+	q2, err := NewDiskQueueWriter(dir, "test", 6, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("re-open NewDiskQueueWriter error: %v", err)
+	}
+	defer q2.Close()
+
+	select {
+	case got := <-q2.ReadChan():
+		if string(got) != "fresh" {
+			t.Fatalf("expected to skip the missing segment and read %q, got %q", "fresh", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ioLoop appears to have stopped after failing to open a missing segment")
+	}
+}
@@ -1,6 +1,9 @@
 package midstore
 
-import "os"
+import (
+	"context"
+	"time"
+)
 
 type Type interface {
 	Marshal() ([]byte, error)
@@ -8,11 +11,11 @@ type Type interface {
 
 // ICache 本地缓存
 type ICache[T Type] interface {
-	Add(row T)        //添加一条数据到本地缓存
-	AddList(rows []T) //添加一批数据到本地缓存
-	Len() int         //本地缓存的长度
-	Start()           //启动后台刷新携程
-	Stop()            //停止后台刷新并释放资源
+	Add(row T) (int, error)        //添加一条数据到本地缓存，返回实际接受的条数（0或1）及背压产生的错误
+	AddList(rows []T) (int, error) //添加一批数据到本地缓存，返回实际接受的条数及背压产生的错误
+	Len() int                      //本地缓存的长度
+	Start()                        //启动后台刷新携程
+	Stop()                         //停止后台刷新并释放资源
 }
 
 // IHandle 本地缓存回调
@@ -21,6 +24,21 @@ type IHandle[T Type] interface {
 	FailedCall(rows []T) error //FlushCall执行失败时回调
 }
 
+// RetryDecision 描述 FlushCall 失败一次之后应该如何处理
+type RetryDecision int
+
+const (
+	RetryDecisionRetry      RetryDecision = iota //按 WithRetry 配置的退避策略继续重试
+	RetryDecisionDeadLetter                      //放弃重试，直接走 FailedCall/本地落盘
+	RetryDecisionDrop                            //直接丢弃，既不重试也不进入 FailedCall/落盘
+)
+
+// Classifier 是 IHandle[T] 的可选扩展：区分瞬时错误和永久错误，
+// 避免把不可重试的永久错误也按 WithRetry 的退避策略反复重试 FlushCall
+type Classifier[T Type] interface {
+	Classify(err error) RetryDecision
+}
+
 // ILog 日志接口
 type ILog interface {
 	Debugf(format string, v ...any)
@@ -29,8 +47,25 @@ type ILog interface {
 	Errorf(format string, v ...any)
 }
 
-// IWriter 落盘策略
+// IObserver 是面向可观测性的回调接口：相比只能打印字符串的 ILog，这里的每个方法都是
+// 类型化的指标，方便对接 Prometheus/OpenTelemetry 等系统，统一带 context.Context 方便
+// 串联 trace。不关心可观测性的场景可以用 NewNoopObserver()。
+type IObserver interface {
+	OnBatchFlushed(ctx context.Context, size int, dur time.Duration, err error) //一次 FlushCall（含重试）结束后回调，err 为最终结果
+	OnFailedCall(ctx context.Context, size int, err error)                      //FailedCall 执行后回调
+	OnDiskWrite(ctx context.Context, bytes int, err error)                      //失败数据落盘（含 SpillToDisk）后回调
+	OnAdd(ctx context.Context, queueLen int)                                    //Add/AddList 成功写入 Cache.data 后回调，queueLen 为当前长度
+}
+
+// IWriter 落盘策略，失败数据最终都会经过 Write 写入到具体的存储介质
 type IWriter interface {
-	GetWriter() (*os.File, error)
+	Write(data []byte) error //写入一条（或一批，由调用方自行拼装）失败数据
 	Close() error
 }
+
+// IReplayWriter 可选能力：IWriter 如果支持把写入的数据重新读取出来，
+// 则实现该接口，Cache.ReplayFailed 会据此把落盘的失败数据回放出来
+type IReplayWriter interface {
+	IWriter
+	ReadChan() <-chan []byte //按写入顺序吐出原始帧数据，由调用方解码
+}
@@ -0,0 +1,21 @@
+package midstore
+
+import (
+	"context"
+	"time"
+)
+
+// noopObserver 是 IObserver 的默认实现，什么都不做
+type noopObserver struct{}
+
+// NewNoopObserver 返回一个空实现的 IObserver，不关心可观测性的场景下作为默认值使用
+func NewNoopObserver() IObserver {
+	return &noopObserver{}
+}
+
+var _ IObserver = &noopObserver{}
+
+func (*noopObserver) OnBatchFlushed(context.Context, int, time.Duration, error) {}
+func (*noopObserver) OnFailedCall(context.Context, int, error)                  {}
+func (*noopObserver) OnDiskWrite(context.Context, int, error)                   {}
+func (*noopObserver) OnAdd(context.Context, int)                                {}
@@ -0,0 +1,100 @@
+package midstore
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OtelObserver 把 IObserver 的回调桥接到 OpenTelemetry：每次 FlushCall 记一个 span，
+// 批大小/耗时/落盘字节数用 metric 记录，方便接入现有的 trace/metrics 后端。
+type OtelObserver struct {
+	tracer trace.Tracer
+
+	flushedTotal  metric.Int64Counter
+	flushedSize   metric.Int64Histogram
+	flushDuration metric.Float64Histogram
+	failedTotal   metric.Int64Counter
+	diskWriteBytes metric.Int64Counter
+	queueLen      metric.Int64Gauge
+}
+
+// NewOtelObserver 用 tracerProvider/meterProvider 构造一个 IObserver，
+// instrumentationName 建议传调用方的模块名，例如 "github.com/xxx/xxx"。
+func NewOtelObserver(tp trace.TracerProvider, mp metric.MeterProvider, instrumentationName string) (*OtelObserver, error) {
+	tracer := tp.Tracer(instrumentationName)
+	meter := mp.Meter(instrumentationName)
+
+	flushedTotal, err := meter.Int64Counter("midstore.flush.total")
+	if err != nil {
+		return nil, err
+	}
+	flushedSize, err := meter.Int64Histogram("midstore.flush.size")
+	if err != nil {
+		return nil, err
+	}
+	flushDuration, err := meter.Float64Histogram("midstore.flush.duration_ms")
+	if err != nil {
+		return nil, err
+	}
+	failedTotal, err := meter.Int64Counter("midstore.failed_call.total")
+	if err != nil {
+		return nil, err
+	}
+	diskWriteBytes, err := meter.Int64Counter("midstore.disk_write.bytes")
+	if err != nil {
+		return nil, err
+	}
+	queueLen, err := meter.Int64Gauge("midstore.queue.length")
+	if err != nil {
+		return nil, err
+	}
+
+	return &OtelObserver{
+		tracer:         tracer,
+		flushedTotal:   flushedTotal,
+		flushedSize:    flushedSize,
+		flushDuration:  flushDuration,
+		failedTotal:    failedTotal,
+		diskWriteBytes: diskWriteBytes,
+		queueLen:       queueLen,
+	}, nil
+}
+
+var _ IObserver = &OtelObserver{}
+
+func (o *OtelObserver) OnBatchFlushed(ctx context.Context, size int, dur time.Duration, err error) {
+	_, span := o.tracer.Start(ctx, "midstore.flush")
+	defer span.End()
+
+	ok := err == nil
+	span.SetAttributes(
+		attribute.Int("size", size),
+		attribute.Bool("success", ok),
+	)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	attrs := metric.WithAttributes(attribute.Bool("success", ok))
+	o.flushedTotal.Add(ctx, 1, attrs)
+	o.flushedSize.Record(ctx, int64(size), attrs)
+	o.flushDuration.Record(ctx, float64(dur.Milliseconds()), attrs)
+}
+
+func (o *OtelObserver) OnFailedCall(ctx context.Context, size int, err error) {
+	attrs := metric.WithAttributes(attribute.Bool("success", err == nil))
+	o.failedTotal.Add(ctx, int64(size), attrs)
+}
+
+func (o *OtelObserver) OnDiskWrite(ctx context.Context, bytes int, err error) {
+	attrs := metric.WithAttributes(attribute.Bool("success", err == nil))
+	o.diskWriteBytes.Add(ctx, int64(bytes), attrs)
+}
+
+func (o *OtelObserver) OnAdd(ctx context.Context, queueLen int) {
+	o.queueLen.Record(ctx, int64(queueLen))
+}
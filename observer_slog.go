@@ -0,0 +1,82 @@
+package midstore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SlogObserver 把 IObserver 的回调桥接到 log/slog，每个方法对应一条结构化日志记录
+type SlogObserver struct {
+	l *slog.Logger
+}
+
+// NewSlogObserver 用 l 构造一个 IObserver，l 为 nil 时使用 slog.Default()
+func NewSlogObserver(l *slog.Logger) *SlogObserver {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogObserver{l: l}
+}
+
+var _ IObserver = &SlogObserver{}
+
+func (o *SlogObserver) OnBatchFlushed(ctx context.Context, size int, dur time.Duration, err error) {
+	if err != nil {
+		o.l.ErrorContext(ctx, "midstore batch flushed", "size", size, "duration", dur, "error", err)
+		return
+	}
+	o.l.InfoContext(ctx, "midstore batch flushed", "size", size, "duration", dur)
+}
+
+func (o *SlogObserver) OnFailedCall(ctx context.Context, size int, err error) {
+	if err != nil {
+		o.l.ErrorContext(ctx, "midstore failed call", "size", size, "error", err)
+		return
+	}
+	o.l.InfoContext(ctx, "midstore failed call", "size", size)
+}
+
+func (o *SlogObserver) OnDiskWrite(ctx context.Context, bytes int, err error) {
+	if err != nil {
+		o.l.ErrorContext(ctx, "midstore disk write", "bytes", bytes, "error", err)
+		return
+	}
+	o.l.DebugContext(ctx, "midstore disk write", "bytes", bytes)
+}
+
+func (o *SlogObserver) OnAdd(ctx context.Context, queueLen int) {
+	o.l.DebugContext(ctx, "midstore add", "queueLen", queueLen)
+}
+
+// SlogLogAdapter 把 ILog 的 printf 风格日志桥接到 log/slog，方便复用已有的 slog.Logger
+type SlogLogAdapter struct {
+	l *slog.Logger
+}
+
+// NewSlogLogAdapter 用 l 构造一个 ILog，l 为 nil 时使用 slog.Default()
+func NewSlogLogAdapter(l *slog.Logger) *SlogLogAdapter {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogAdapter{l: l}
+}
+
+var _ ILog = &SlogLogAdapter{}
+
+func (a *SlogLogAdapter) Debugf(format string, v ...any) {
+	a.l.Debug(fmt.Sprintf(format, v...))
+}
+
+func (a *SlogLogAdapter) Infof(format string, v ...any) {
+	a.l.Info(fmt.Sprintf(format, v...))
+}
+
+func (a *SlogLogAdapter) Warnf(format string, v ...any) {
+	a.l.Warn(fmt.Sprintf(format, v...))
+}
+
+func (a *SlogLogAdapter) Errorf(format string, v ...any) {
+	a.l.Error(fmt.Sprintf(format, v...))
+}
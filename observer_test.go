@@ -0,0 +1,181 @@
+package midstore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingObserver 记录每个 IObserver 回调被调用的次数和最后一次收到的参数，
+// 用来断言 Cache[T] 在各个环节确实按预期把事件喂给了 IObserver
+type recordingObserver struct {
+	mu sync.Mutex
+
+	addCalls        int
+	lastAddQueueLen int
+
+	batchFlushedCalls int
+	lastBatchTotal    int
+	lastBatchErr      error
+
+	failedCallCalls int
+	lastFailedTotal int
+	lastFailedErr   error
+
+	diskWriteCalls int
+	lastDiskErr    error
+}
+
+var _ IObserver = &recordingObserver{}
+
+func (o *recordingObserver) OnAdd(_ context.Context, queueLen int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.addCalls++
+	o.lastAddQueueLen = queueLen
+}
+
+func (o *recordingObserver) OnBatchFlushed(_ context.Context, total int, _ time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.batchFlushedCalls++
+	o.lastBatchTotal = total
+	o.lastBatchErr = err
+}
+
+func (o *recordingObserver) OnFailedCall(_ context.Context, total int, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failedCallCalls++
+	o.lastFailedTotal = total
+	o.lastFailedErr = err
+}
+
+func (o *recordingObserver) OnDiskWrite(_ context.Context, _ int, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.diskWriteCalls++
+	o.lastDiskErr = err
+}
+
+func (o *recordingObserver) snapshot() recordingObserver {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return recordingObserver{
+		addCalls:          o.addCalls,
+		lastAddQueueLen:   o.lastAddQueueLen,
+		batchFlushedCalls: o.batchFlushedCalls,
+		lastBatchTotal:    o.lastBatchTotal,
+		lastBatchErr:      o.lastBatchErr,
+		failedCallCalls:   o.failedCallCalls,
+		lastFailedTotal:   o.lastFailedTotal,
+		lastFailedErr:     o.lastFailedErr,
+		diskWriteCalls:    o.diskWriteCalls,
+		lastDiskErr:       o.lastDiskErr,
+	}
+}
+
+func TestObserverOnAddReceivesQueueLen(t *testing.T) {
+	ob := &recordingObserver{}
+	c := NewCache[elem](newMyHandle(), WithObserver(ob))
+
+	if _, err := c.Add(elem{Id: 1}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if _, err := c.Add(elem{Id: 2}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	got := ob.snapshot()
+	if got.addCalls != 2 {
+		t.Fatalf("expected 2 OnAdd calls, got %d", got.addCalls)
+	}
+	if got.lastAddQueueLen != 2 {
+		t.Fatalf("expected last OnAdd queue len 2, got %d", got.lastAddQueueLen)
+	}
+}
+
+func TestObserverOnBatchFlushedOnSuccess(t *testing.T) {
+	ob := &recordingObserver{}
+	h := &retryHandle{failTimes: 0}
+	c := NewCache[elem](h, WithObserver(ob))
+
+	if _, err := c.AddList([]elem{{Id: 1}, {Id: 2}, {Id: 3}}); err != nil {
+		t.Fatalf("AddList error: %v", err)
+	}
+	c.flush()
+
+	got := ob.snapshot()
+	if got.batchFlushedCalls != 1 {
+		t.Fatalf("expected 1 OnBatchFlushed call, got %d", got.batchFlushedCalls)
+	}
+	if got.lastBatchTotal != 3 {
+		t.Fatalf("expected OnBatchFlushed total 3, got %d", got.lastBatchTotal)
+	}
+	if got.lastBatchErr != nil {
+		t.Fatalf("expected OnBatchFlushed err nil on success, got %v", got.lastBatchErr)
+	}
+	if got.failedCallCalls != 0 {
+		t.Fatalf("expected FailedCall to not run on success, got %d calls", got.failedCallCalls)
+	}
+}
+
+// TestObserverOnFailedCallAfterFlushExhausted 验证 FlushCall 重试耗尽之后，
+// OnBatchFlushed 先报告失败，再由 FailedCall 触发 OnFailedCall。
+func TestObserverOnFailedCallAfterFlushExhausted(t *testing.T) {
+	ob := &recordingObserver{}
+	// myHandle 的 FlushCall 和 FailedCall 都固定返回 error
+	c := NewCache[elem](newMyHandle(), WithObserver(ob), WithRetry(1, time.Millisecond, time.Millisecond, 0))
+
+	if _, err := c.AddList([]elem{{Id: 1}, {Id: 2}}); err != nil {
+		t.Fatalf("AddList error: %v", err)
+	}
+	c.flush()
+
+	got := ob.snapshot()
+	if got.batchFlushedCalls != 1 {
+		t.Fatalf("expected 1 OnBatchFlushed call, got %d", got.batchFlushedCalls)
+	}
+	if got.lastBatchErr == nil {
+		t.Fatal("expected OnBatchFlushed to report the FlushCall error")
+	}
+	if got.failedCallCalls != 1 {
+		t.Fatalf("expected 1 OnFailedCall call, got %d", got.failedCallCalls)
+	}
+	if got.lastFailedTotal != 2 {
+		t.Fatalf("expected OnFailedCall total 2, got %d", got.lastFailedTotal)
+	}
+	if got.lastFailedErr == nil {
+		t.Fatal("expected OnFailedCall to report the FailedCall error")
+	}
+}
+
+func TestObserverOnDiskWriteOnSpill(t *testing.T) {
+	ob := &recordingObserver{}
+	dir := t.TempDir()
+	dq, err := NewDiskQueueWriter(dir, "observer-spill", 1024*1024, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskQueueWriter error: %v", err)
+	}
+	defer dq.Close()
+
+	c := NewCache[elem](newMyHandle(),
+		WithHardCapacity(1),
+		WithOverflowPolicy(SpillToDisk()),
+		WithWriter(dq),
+		WithObserver(ob),
+	)
+
+	if _, err := c.AddList([]elem{{Id: 1}, {Id: 2}}); err != nil {
+		t.Fatalf("AddList error: %v", err)
+	}
+
+	got := ob.snapshot()
+	if got.diskWriteCalls == 0 {
+		t.Fatal("expected at least 1 OnDiskWrite call for the spilled row")
+	}
+	if got.lastDiskErr != nil {
+		t.Fatalf("expected OnDiskWrite err nil on successful spill, got %v", got.lastDiskErr)
+	}
+}
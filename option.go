@@ -15,6 +15,24 @@ type Options struct {
 	enableLocalBackup bool   //是否启用失败后回调失败落盘
 	writer            IWriter
 	failedBackRows    bool //true:一批一行,false:一批N行
+	codec             any  //Codec[T]，实际使用时再按 T 做类型断言
+	hardCapacity      int  //Cache.data 硬上限，<=0 表示不限制（保留旧的无界增长行为）
+	overflowPolicy    OverflowPolicy
+
+	walEnabled         bool
+	walDir             string
+	walSyncEvery       int
+	walMaxSegmentBytes int64
+
+	shardCount   int //分片数，只有 NewShardedCache 会读取，NewCache 忽略
+	shardKeyFunc any //KeyFunc[T]，实际使用时再按 T 做类型断言
+
+	retryMaxAttempts int //FlushCall 最多尝试几次，<=0 等价于 1（不重试，兼容旧行为）
+	retryInitial     time.Duration
+	retryMax         time.Duration
+	retryJitter      float64
+
+	observer IObserver
 }
 
 type Option func(*Options)
@@ -71,3 +89,98 @@ func WithFailedFileDirAndMode(dir string, filename string, mode os.FileMode) Opt
 		}
 	}
 }
+
+// WithWriter 自定义失败落盘的写入方式，比如 NewDiskQueueWriter 返回的磁盘队列。
+// 设置后 failedFileDir/failedFileDirMode/failedFileName 对 defaultWriter 的配置将不再生效。
+func WithWriter(w IWriter) Option {
+	return func(o *Options) {
+		if w == nil {
+			return
+		}
+		o.enableLocalBackup = true
+		o.writer = w
+	}
+}
+
+// WithCodec 自定义失败数据的序列化方式，不设置时沿用 json.Marshal 的默认行为
+// （FailedBackRows/FailedBackRow，由 WithFailedBackRows 控制是一批一行还是一批N行）。
+func WithCodec[T Type](c Codec[T]) Option {
+	return func(o *Options) {
+		o.codec = c
+	}
+}
+
+// WithHardCapacity 设置 Cache.data 的硬上限，达到上限后按 WithOverflowPolicy 配置的策略处理，
+// 不设置（或 <=0）时保持旧版本无界增长的行为。
+func WithHardCapacity(n int) Option {
+	return func(o *Options) {
+		o.hardCapacity = n
+	}
+}
+
+// WithOverflowPolicy 配置达到 WithHardCapacity 上限后的处理策略，默认是 DropNewest()
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *Options) {
+		o.overflowPolicy = policy
+	}
+}
+
+// WithWAL 开启预写日志：Add/AddList 在数据进入 Cache.data 之前先落一条 WAL 记录，
+// FlushCall 成功处理完一批后写 checkpoint。NewCache 会据此重放崩溃前未确认的数据。
+// syncEvery 为每写入多少条 fsync 一次，maxSegmentBytes 为单个分段文件的滚动阈值。
+func WithWAL(dir string, syncEvery int, maxSegmentBytes int64) Option {
+	return func(o *Options) {
+		o.walEnabled = dir != ""
+		if dir != "" {
+			o.walDir = dir
+		}
+		if syncEvery > 0 {
+			o.walSyncEvery = syncEvery
+		}
+		if maxSegmentBytes > 0 {
+			o.walMaxSegmentBytes = maxSegmentBytes
+		}
+	}
+}
+
+// WithShards 只对 NewShardedCache 生效：把数据按 key(row) 哈希路由到 n 个独立的 Cache[T] 分片，
+// 每个分片拥有自己的后台 goroutine、ticker 和刷新 channel，FlushCall 按分片并行执行。
+func WithShards[T Type](n int, key KeyFunc[T]) Option {
+	return func(o *Options) {
+		if n <= 0 {
+			n = 1
+		}
+		o.shardCount = n
+		o.shardKeyFunc = key
+	}
+}
+
+// WithRetry 开启 FlushCall 失败后的指数退避重试：第 i 次重试前等待
+// initial*2^(i-1)（不超过 max）再叠加 ±jitter 比例的随机抖动。IHandle[T] 如果实现了
+// Classifier[T]，重试过程中每次失败都会调用 Classify 判断是继续重试、直接进入
+// FailedCall/落盘（DeadLetter），还是直接丢弃（Drop）。
+func WithRetry(maxAttempts int, initial, max time.Duration, jitter float64) Option {
+	return func(o *Options) {
+		if maxAttempts <= 0 {
+			maxAttempts = 1
+		}
+		o.retryMaxAttempts = maxAttempts
+		if initial > 0 {
+			o.retryInitial = initial
+		}
+		if max > 0 {
+			o.retryMax = max
+		}
+		o.retryJitter = jitter
+	}
+}
+
+// WithObserver 自定义可观测性回调，不设置时使用 NewNoopObserver()
+func WithObserver(ob IObserver) Option {
+	return func(o *Options) {
+		if ob == nil {
+			ob = NewNoopObserver()
+		}
+		o.observer = ob
+	}
+}
@@ -0,0 +1,45 @@
+package midstore
+
+import "time"
+
+type overflowKind int
+
+const (
+	overflowDropNewest overflowKind = iota
+	overflowDropOldest
+	overflowBlockWithTimeout
+	overflowSpillToDisk
+)
+
+// OverflowPolicy 描述 Cache.data 达到 WithHardCapacity 设置的硬上限后如何处理新数据
+type OverflowPolicy struct {
+	kind         overflowKind
+	blockTimeout time.Duration
+}
+
+// DropNewest 丢弃本次要写入的新数据，保留缓存里已有的数据（默认策略）
+func DropNewest() OverflowPolicy {
+	return OverflowPolicy{kind: overflowDropNewest}
+}
+
+// DropOldest 丢弃缓存里最老的一条数据，腾出空间给新数据
+func DropOldest() OverflowPolicy {
+	return OverflowPolicy{kind: overflowDropOldest}
+}
+
+// BlockWithTimeout 阻塞等待 flush 腾出空间，超过 d 仍未腾出空间则放弃并返回错误
+func BlockWithTimeout(d time.Duration) OverflowPolicy {
+	return OverflowPolicy{kind: overflowBlockWithTimeout, blockTimeout: d}
+}
+
+// SpillToDisk 把超出容量的数据直接落盘（复用 Options.writer/codec），不进入 FlushCall 流程
+func SpillToDisk() OverflowPolicy {
+	return OverflowPolicy{kind: overflowSpillToDisk}
+}
+
+// Stats 记录 Cache 在背压场景下的运行状态，供操作人员观察流水线健康度
+type Stats struct {
+	Dropped uint64 //因 DropNewest/DropOldest 策略丢弃的条数
+	Spilled uint64 //因 SpillToDisk 策略落盘的条数
+	Blocked uint64 //因 BlockWithTimeout 策略等待超时放弃的条数
+}
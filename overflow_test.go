@@ -0,0 +1,173 @@
+package midstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestOverflowDropNewest 验证默认策略：超出 HardCapacity 的新数据被丢弃，
+// 缓存里已有的数据保持不变，Stats().Dropped 按丢弃条数累加。
+func TestOverflowDropNewest(t *testing.T) {
+	c := NewCache[elem](newMyHandle(), WithHardCapacity(2), WithOverflowPolicy(DropNewest()))
+
+	accepted, err := c.AddList([]elem{{Id: 1}, {Id: 2}, {Id: 3}})
+	if err != nil {
+		t.Fatalf("AddList error: %v", err)
+	}
+	if accepted != 2 {
+		t.Fatalf("expected 2 accepted, got %d", accepted)
+	}
+	if got := c.Len(); got != 2 {
+		t.Fatalf("expected cache len 2, got %d", got)
+	}
+	if got := c.Stats().Dropped; got != 1 {
+		t.Fatalf("expected 1 dropped, got %d", got)
+	}
+}
+
+// TestOverflowDropOldest 验证 DropOldest 策略：超出容量时淘汰最老的一条，
+// 新数据总是能进入缓存。
+func TestOverflowDropOldest(t *testing.T) {
+	c := NewCache[elem](newMyHandle(), WithHardCapacity(2), WithOverflowPolicy(DropOldest()))
+
+	accepted, err := c.AddList([]elem{{Id: 1}, {Id: 2}, {Id: 3}})
+	if err != nil {
+		t.Fatalf("AddList error: %v", err)
+	}
+	if accepted != 3 {
+		t.Fatalf("expected 3 accepted (every row lands in the cache, oldest just gets evicted), got %d", accepted)
+	}
+	if got := c.Len(); got != 2 {
+		t.Fatalf("expected cache len 2, got %d", got)
+	}
+	if got := c.Stats().Dropped; got != 1 {
+		t.Fatalf("expected 1 dropped (the evicted oldest row), got %d", got)
+	}
+}
+
+// TestOverflowBlockWithTimeout 验证 BlockWithTimeout 策略：容量被占满时阻塞等待，
+// 超时之后放弃并返回错误，Stats().Blocked 累加。
+func TestOverflowBlockWithTimeout(t *testing.T) {
+	c := NewCache[elem](newMyHandle(),
+		WithHardCapacity(1),
+		WithOverflowPolicy(BlockWithTimeout(time.Millisecond*50)),
+	)
+
+	if _, err := c.Add(elem{Id: 1}); err != nil {
+		t.Fatalf("first Add should not block: %v", err)
+	}
+
+	start := time.Now()
+	_, err := c.Add(elem{Id: 2})
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected second Add to time out since nothing drains the cache")
+	}
+	if elapsed < time.Millisecond*50 {
+		t.Fatalf("expected Add to block for roughly the configured timeout, only waited %s", elapsed)
+	}
+	if got := c.Stats().Blocked; got != 1 {
+		t.Fatalf("expected 1 blocked, got %d", got)
+	}
+}
+
+// TestOverflowSpillToDisk 验证 SpillToDisk 策略：超出容量的数据落盘、不进入
+// FlushCall 流程，落盘内容可以通过 ReplayFailed 完整找回。落盘 I/O 不在持有
+// c.rw 锁期间发生这一点由 TestAddWithOverflowDoesNotBlockOnSpill 验证。
+func TestOverflowSpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+	dq, err := NewDiskQueueWriter(dir, "spill", 1024*1024, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskQueueWriter error: %v", err)
+	}
+	defer dq.Close()
+
+	c := NewCache[elem](newMyHandle(),
+		WithHardCapacity(1),
+		WithOverflowPolicy(SpillToDisk()),
+		WithWriter(dq),
+		// 没有配置 codec 时 spillRows 把整批数据编码成一个 FailedBackRows（一批一行）落盘，
+		// decodeFrame 需要用同样的约定去解析，否则回放会被当成格式不匹配而跳过
+		WithFailedBackRows(true),
+	)
+
+	accepted, err := c.AddList([]elem{{Id: 1}, {Id: 2}, {Id: 3}})
+	if err != nil {
+		t.Fatalf("AddList error: %v", err)
+	}
+	if accepted != 1 {
+		t.Fatalf("expected 1 accepted into the in-memory cache, got %d", accepted)
+	}
+	if got := c.Stats().Spilled; got != 2 {
+		t.Fatalf("expected 2 spilled, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	out, err := c.ReplayFailed(ctx, 10)
+	if err != nil {
+		t.Fatalf("ReplayFailed error: %v", err)
+	}
+
+	var spilled []elem
+	for batch := range out {
+		spilled = append(spilled, batch...)
+	}
+	if len(spilled) != 2 {
+		t.Fatalf("expected to replay 2 spilled rows, got %d: %+v", len(spilled), spilled)
+	}
+}
+
+// TestAddWithOverflowDoesNotBlockOnSpill 验证 SpillToDisk 触发的落盘 I/O 不会在
+// c.rw 锁内发生：用一个人为变慢的 writer 模拟慢磁盘，确认并发的 Len() 调用不会被
+// 卡住等 I/O 完成。
+func TestAddWithOverflowDoesNotBlockOnSpill(t *testing.T) {
+	slow := &slowWriter{delay: time.Millisecond * 200}
+	c := NewCache[elem](newMyHandle(),
+		WithHardCapacity(1),
+		WithOverflowPolicy(SpillToDisk()),
+		WithWriter(slow),
+	)
+
+	if _, err := c.Add(elem{Id: 1}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.Add(elem{Id: 2}) // 触发 spillRows，内部 Write 会阻塞 200ms
+	}()
+
+	// 给 Add 一点时间先拿到锁、把要落盘的行攒出来
+	time.Sleep(time.Millisecond * 20)
+
+	lenDone := make(chan struct{})
+	go func() {
+		defer close(lenDone)
+		c.Len()
+	}()
+
+	select {
+	case <-lenDone:
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("Len() was blocked by a concurrent slow disk spill, lock is held during I/O")
+	}
+
+	<-done
+}
+
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (s *slowWriter) Write(data []byte) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s *slowWriter) Close() error {
+	return nil
+}
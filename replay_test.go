@@ -0,0 +1,45 @@
+package midstore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestReplayFailedDeliversFinalBatchOnCtxDone 复现并锁定一个曾经存在的 bug：
+// flushBatch 在 ctx 已经 Done() 的终止态下，还用 select { out <- batch; case <-ctx.Done() }
+// 去竞争一个已经就绪的 case，Go 会在两个就绪分支之间伪随机选择，导致最后一批数据被
+// 静默丢弃。只攒了 2 行（不够 batchSize 触发中途 flush），唯一的 flush 时机就是 ctx
+// 超时那一刻，反复跑多轮确保每一轮都能拿到完整的最后一批（见 #chunk0-1 review fix）。
+func TestReplayFailedDeliversFinalBatchOnCtxDone(t *testing.T) {
+	const iterations = 30
+
+	for i := 0; i < iterations; i++ {
+		dir := t.TempDir()
+
+		dq, err := NewDiskQueueWriter(dir, "test", 1024*1024, 1000, time.Hour)
+		if err != nil {
+			t.Fatalf("iteration %d: NewDiskQueueWriter error: %v", i, err)
+		}
+
+		c := NewCache[elem](newMyHandle(), WithWriter(dq))
+		c.failedCallBack([]elem{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		out, err := c.ReplayFailed(ctx, 10)
+		if err != nil {
+			t.Fatalf("iteration %d: ReplayFailed error: %v", i, err)
+		}
+
+		var got []elem
+		for batch := range out {
+			got = append(got, batch...)
+		}
+		cancel()
+		_ = dq.Close()
+
+		if len(got) != 2 {
+			t.Fatalf("iteration %d: expected the final batch of 2 rows to be delivered before ctx.Done closed out, got %d", i, len(got))
+		}
+	}
+}
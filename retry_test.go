@@ -0,0 +1,138 @@
+package midstore
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retryHandle 是一个可编程失败次数的 IHandle[elem]，FlushCall 在 failTimes 次失败之后
+// 开始返回成功，用来驱动 flushWithRetry 的退避重试路径
+type retryHandle struct {
+	failTimes int32
+	attempts  atomic.Int32
+}
+
+func (h *retryHandle) FlushCall(rows []elem) error {
+	n := h.attempts.Add(1)
+	if n <= h.failTimes {
+		return fmt.Errorf("transient error, attempt %d", n)
+	}
+	return nil
+}
+
+func (h *retryHandle) FailedCall(rows []elem) error {
+	return nil
+}
+
+func TestFlushWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	h := &retryHandle{failTimes: 2}
+	c := NewCache[elem](h, WithRetry(5, time.Millisecond, time.Millisecond*10, 0))
+
+	ok, err := c.flushWithRetry([]elem{{Id: 1}})
+	if !ok || err != nil {
+		t.Fatalf("expected retry to eventually succeed, got ok=%v err=%v", ok, err)
+	}
+	if got := h.attempts.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts (2 failures + 1 success), got %d", got)
+	}
+}
+
+func TestFlushWithRetryExhaustsMaxAttempts(t *testing.T) {
+	h := &retryHandle{failTimes: 100}
+	c := NewCache[elem](h, WithRetry(3, time.Millisecond, time.Millisecond*10, 0))
+
+	ok, err := c.flushWithRetry([]elem{{Id: 1}})
+	if ok || err == nil {
+		t.Fatalf("expected retries to be exhausted with an error, got ok=%v err=%v", ok, err)
+	}
+	if got := h.attempts.Load(); got != 3 {
+		t.Fatalf("expected exactly maxAttempts=3 attempts, got %d", got)
+	}
+}
+
+// classifyingHandle 总是让 FlushCall 失败，分类结果由 decision 决定
+type classifyingHandle struct {
+	decision RetryDecision
+	attempts atomic.Int32
+}
+
+func (h *classifyingHandle) FlushCall(rows []elem) error {
+	h.attempts.Add(1)
+	return fmt.Errorf("permanent error")
+}
+
+func (h *classifyingHandle) FailedCall(rows []elem) error {
+	return nil
+}
+
+func (h *classifyingHandle) Classify(err error) RetryDecision {
+	return h.decision
+}
+
+var _ Classifier[elem] = &classifyingHandle{}
+
+func TestFlushWithRetryClassifierDrop(t *testing.T) {
+	h := &classifyingHandle{decision: RetryDecisionDrop}
+	c := NewCache[elem](h, WithRetry(5, time.Millisecond, time.Millisecond*10, 0))
+
+	ok, err := c.flushWithRetry([]elem{{Id: 1}})
+	if !ok || err != nil {
+		t.Fatalf("expected Drop to report ok=true, err=nil (discard, no further handling), got ok=%v err=%v", ok, err)
+	}
+	if got := h.attempts.Load(); got != 1 {
+		t.Fatalf("expected Drop to stop after the first failed attempt, got %d attempts", got)
+	}
+}
+
+func TestFlushWithRetryClassifierDeadLetter(t *testing.T) {
+	h := &classifyingHandle{decision: RetryDecisionDeadLetter}
+	c := NewCache[elem](h, WithRetry(5, time.Millisecond, time.Millisecond*10, 0))
+
+	ok, err := c.flushWithRetry([]elem{{Id: 1}})
+	if ok || err == nil {
+		t.Fatalf("expected DeadLetter to report ok=false with an error, got ok=%v err=%v", ok, err)
+	}
+	if got := h.attempts.Load(); got != 1 {
+		t.Fatalf("expected DeadLetter to stop after the first failed attempt instead of retrying, got %d attempts", got)
+	}
+}
+
+func TestFlushWithRetryClassifierRetry(t *testing.T) {
+	h := &classifyingHandleThenSucceed{decision: RetryDecisionRetry, succeedAfter: 2}
+	c := NewCache[elem](h, WithRetry(5, time.Millisecond, time.Millisecond*10, 0))
+
+	ok, err := c.flushWithRetry([]elem{{Id: 1}})
+	if !ok || err != nil {
+		t.Fatalf("expected Retry decision to keep retrying until success, got ok=%v err=%v", ok, err)
+	}
+	if got := h.attempts.Load(); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
+
+// classifyingHandleThenSucceed 让分类结果一直是 Retry，直到第 succeedAfter 次尝试才成功
+type classifyingHandleThenSucceed struct {
+	decision     RetryDecision
+	succeedAfter int32
+	attempts     atomic.Int32
+}
+
+func (h *classifyingHandleThenSucceed) FlushCall(rows []elem) error {
+	n := h.attempts.Add(1)
+	if n >= h.succeedAfter {
+		return nil
+	}
+	return fmt.Errorf("transient error, attempt %d", n)
+}
+
+func (h *classifyingHandleThenSucceed) FailedCall(rows []elem) error {
+	return nil
+}
+
+func (h *classifyingHandleThenSucceed) Classify(err error) RetryDecision {
+	return h.decision
+}
+
+var _ Classifier[elem] = &classifyingHandleThenSucceed{}
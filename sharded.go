@@ -0,0 +1,165 @@
+package midstore
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// KeyFunc 计算一行数据的路由 key，ShardedCache 用它的哈希值选出固定的分片，
+// 保证同一个 key 的数据总是落在同一个分片、同一个刷新批次里
+type KeyFunc[T Type] func(row T) uint64
+
+// ShardedCache 把数据按 KeyFunc 哈希路由到 N 个相互独立的 Cache[T] 分片，每个分片
+// 有自己的锁、后台 goroutine、ticker 和刷新 channel，FlushCall 按分片并行执行。
+// 用来突破单个 Cache[T] 里那把 sync.RWMutex 和单个后台刷新 goroutine 的吞吐上限。
+// 对外仍然实现 ICache[T]，单分片场景（n==1）和原来的 Cache[T] 行为完全一致。
+type ShardedCache[T Type] struct {
+	shards  []*Cache[T]
+	keyFunc KeyFunc[T]
+}
+
+var _ ICache[Type] = &ShardedCache[Type]{}
+
+// NewShardedCache 创建一个分片缓存，分片数量和路由函数由 WithShards 配置，
+// 其余 Option（WithMaxLength、WithWAL 等）会应用到每一个分片；分片数大于 1 时，
+// WAL 目录和失败落盘目录会被改到各自的 shard-<i> 子目录，WithWriter 配置的 writer 会被忽略
+// （见 withShardNamespace）。
+func NewShardedCache[T Type](h IHandle[T], opts ...Option) *ShardedCache[T] {
+	probe := &Options{}
+	for _, o := range opts {
+		o(probe)
+	}
+
+	n := probe.shardCount
+	if n <= 0 {
+		n = 1
+	}
+
+	keyFunc, _ := probe.shardKeyFunc.(KeyFunc[T])
+	if keyFunc == nil {
+		keyFunc = func(T) uint64 { return 0 }
+	}
+
+	if n > 1 && probe.writer != nil {
+		log := probe.log
+		if log == nil {
+			log = newLog()
+		}
+		log.Errorf("NewShardedCache: WithWriter 配置的是一个共享的 writer 实例，多个分片写入同一份文件会互相踩踏，已忽略该 writer，每个分片改用按 shard 隔离的默认落盘目录")
+	}
+
+	shards := make([]*Cache[T], n)
+	for i := 0; i < n; i++ {
+		shardOpts := append(append([]Option{}, opts...), withShardNamespace[T](i, n))
+		shards[i] = NewCache[T](h, shardOpts...)
+	}
+
+	return &ShardedCache[T]{
+		shards:  shards,
+		keyFunc: keyFunc,
+	}
+}
+
+// withShardNamespace 是 NewShardedCache 内部使用的 Option，在其余 Option 应用完之后
+// 追加执行：把 WAL 目录、失败落盘目录按 shard 下标隔离到独立子目录，避免多个分片的
+// wal[T]/defaultWriter 写到同一份文件里，导致序列号、checkpoint 互相覆盖，
+// 以及重启恢复时同一批数据被每个分片各自重放一遍。共享的自定义 writer（WithWriter）
+// 没法按目录隔离，直接丢弃，让分片退回按 shard 隔离的默认 writer。
+func withShardNamespace[T Type](i, n int) Option {
+	return func(o *Options) {
+		if n <= 1 {
+			return
+		}
+		suffix := fmt.Sprintf("shard-%d", i)
+		if o.walEnabled && o.walDir != "" {
+			o.walDir = filepath.Join(o.walDir, suffix)
+		}
+		if o.writer != nil {
+			o.writer = nil
+		}
+		if o.enableLocalBackup && o.failedFileDir != "" {
+			o.failedFileDir = filepath.Join(o.failedFileDir, suffix)
+		}
+	}
+}
+
+func (s *ShardedCache[T]) shardIndex(row T) int {
+	return int(s.keyFunc(row) % uint64(len(s.shards)))
+}
+
+// Add 按 KeyFunc(row) 路由到单个分片
+func (s *ShardedCache[T]) Add(row T) (int, error) {
+	return s.shards[s.shardIndex(row)].Add(row)
+}
+
+// AddList 先按分片分组再批量下发，避免同一批里每条数据都单独加一次锁
+func (s *ShardedCache[T]) AddList(rows []T) (int, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	grouped := make(map[int][]T, len(s.shards))
+	for _, row := range rows {
+		idx := s.shardIndex(row)
+		grouped[idx] = append(grouped[idx], row)
+	}
+
+	var total int
+	var firstErr error
+	for idx, group := range grouped {
+		accepted, err := s.shards[idx].AddList(group)
+		total += accepted
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return total, firstErr
+}
+
+// Len 返回所有分片 Cache.data 长度之和
+func (s *ShardedCache[T]) Len() int {
+	total := 0
+	for _, shard := range s.shards {
+		total += shard.Len()
+	}
+	return total
+}
+
+// Start 启动每个分片自己的后台刷新协程
+func (s *ShardedCache[T]) Start() {
+	for _, shard := range s.shards {
+		shard.Start()
+	}
+}
+
+// Stop 并行停止所有分片，等待全部分片都完成收尾刷新后再返回
+func (s *ShardedCache[T]) Stop() {
+	var wg sync.WaitGroup
+	wg.Add(len(s.shards))
+	for _, shard := range s.shards {
+		shard := shard
+		go func() {
+			defer wg.Done()
+			shard.Stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// Stats 汇总所有分片的背压计数
+func (s *ShardedCache[T]) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		st := shard.Stats()
+		total.Dropped += st.Dropped
+		total.Spilled += st.Spilled
+		total.Blocked += st.Blocked
+	}
+	return total
+}
+
+// Shards 返回底层分片，供需要单独操作某个分片（比如 ReplayFailed）的场景使用
+func (s *ShardedCache[T]) Shards() []*Cache[T] {
+	return s.shards
+}
@@ -0,0 +1,359 @@
+package midstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// wal 是 Cache[T] 的预写日志：Add/AddList 在数据真正进入 c.data 之前先写一条带递增
+// 序列号的记录，FlushCall 成功处理完一批之后把序列号对应的 checkpoint 落盘。
+// 进程被杀死后重启，NewCache 会重放 checkpoint 之后的记录，恢复到 c.data 里。
+// 分段方式参考了 DiskQueueWriter：按 maxSegmentBytes 滚动，checkpoint 完全覆盖的段会被删除。
+type wal[T Type] struct {
+	mu sync.Mutex
+
+	dir             string
+	syncEvery       int
+	maxSegmentBytes int64
+
+	writeFile       *os.File
+	segmentNum      int64
+	segmentPos      int64
+	writesSinceSync int
+
+	nextSeq       uint64
+	checkpointSeq uint64
+	segmentMaxSeq map[int64]uint64 //段号 -> 该段写入过的最大 seq，用于 checkpoint 后清理旧段
+
+	codec Codec[T]
+	log   ILog
+}
+
+// newWAL 打开（或创建）dir 下的 WAL，扫描已有分段完成恢复，返回 checkpoint 之后
+// 尚未被确认消费的记录（按写入顺序），供 NewCache 重新放回 c.data
+func newWAL[T Type](dir string, syncEvery int, maxSegmentBytes int64, codec Codec[T], log ILog) (*wal[T], []T, error) {
+	if syncEvery <= 0 {
+		syncEvery = 1
+	}
+	if maxSegmentBytes <= 0 {
+		maxSegmentBytes = 64 * 1024 * 1024
+	}
+	if log == nil {
+		log = newLog()
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	w := &wal[T]{
+		dir:             dir,
+		syncEvery:       syncEvery,
+		maxSegmentBytes: maxSegmentBytes,
+		segmentMaxSeq:   make(map[int64]uint64),
+		codec:           codec,
+		log:             log,
+	}
+
+	if err := w.loadCheckpoint(); err != nil {
+		return nil, nil, err
+	}
+
+	segments, err := w.listSegments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var recovered []T
+	var maxSeqSeen uint64
+	var lastSegNum, lastSegSize int64
+
+	for i, segNum := range segments {
+		rows, maxSeq, size, err := w.scanSegment(segNum)
+		if err != nil {
+			return nil, nil, err
+		}
+		recovered = append(recovered, rows...)
+		w.segmentMaxSeq[segNum] = maxSeq
+		if maxSeq > maxSeqSeen {
+			maxSeqSeen = maxSeq
+		}
+		if i == len(segments)-1 {
+			lastSegNum = segNum
+			lastSegSize = size
+		}
+	}
+
+	w.nextSeq = maxSeqSeen + 1
+	w.segmentNum = lastSegNum
+	w.segmentPos = lastSegSize
+
+	w.cleanupCoveredSegments()
+
+	// 把最后一个分段截断到能被完整解析的长度，否则 O_APPEND 的新记录会接在残缺记录后面
+	if len(segments) > 0 {
+		if err = os.Truncate(w.segmentFileName(lastSegNum), lastSegSize); err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+
+	if err = w.openSegmentForWrite(); err != nil {
+		return nil, nil, err
+	}
+
+	return w, recovered, nil
+}
+
+func (w *wal[T]) segmentFileName(num int64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("wal.%06d.seg", num))
+}
+
+func (w *wal[T]) checkpointFileName() string {
+	return filepath.Join(w.dir, "wal.checkpoint")
+}
+
+func (w *wal[T]) listSegments() ([]int64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nums []int64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		var n int64
+		if _, err = fmt.Sscanf(e.Name(), "wal.%06d.seg", &n); err == nil {
+			nums = append(nums, n)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	return nums, nil
+}
+
+// scanSegment 顺序读取一个分段文件，返回 checkpoint 之后的记录、该段的最大 seq、
+// 以及能被完整解析的字节数（遇到被截断的尾部记录直接截断，不当成错误处理）
+func (w *wal[T]) scanSegment(segNum int64) (rows []T, maxSeq uint64, size int64, err error) {
+	f, err := os.Open(w.segmentFileName(segNum))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, 0, nil
+		}
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var hdr [12]byte
+		if _, err = io.ReadFull(r, hdr[:]); err != nil {
+			break // EOF 或尾部记录头被截断，停止扫描
+		}
+
+		seq := binary.BigEndian.Uint64(hdr[0:8])
+		bodyLen := binary.BigEndian.Uint32(hdr[8:12])
+
+		body := make([]byte, bodyLen)
+		if _, err = io.ReadFull(r, body); err != nil {
+			break // 尾部记录体被截断，停止扫描
+		}
+
+		size += int64(len(hdr)) + int64(bodyLen)
+		if seq > maxSeq {
+			maxSeq = seq
+		}
+
+		if seq > w.checkpointSeq {
+			decoded, derr := w.decode(body)
+			if derr != nil {
+				w.log.Errorf("wal decode entry error, seq: %d, err: %v", seq, derr)
+				continue
+			}
+			rows = append(rows, decoded...)
+		}
+	}
+
+	return rows, maxSeq, size, nil
+}
+
+func (w *wal[T]) loadCheckpoint() error {
+	f, err := os.Open(w.checkpointFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fscanf(f, "%d", &w.checkpointSeq)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// persistCheckpoint 要求调用方已持有 mu
+func (w *wal[T]) persistCheckpoint() error {
+	tmp := w.checkpointFileName() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(f, "%d", w.checkpointSeq); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.checkpointFileName())
+}
+
+// cleanupCoveredSegments 要求调用方已持有 mu（或在单线程的恢复阶段调用）
+func (w *wal[T]) cleanupCoveredSegments() {
+	for segNum, maxSeq := range w.segmentMaxSeq {
+		if segNum == w.segmentNum {
+			continue // 当前写入段即便被完全覆盖也不删，避免和 append 竞争
+		}
+		if maxSeq <= w.checkpointSeq {
+			_ = os.Remove(w.segmentFileName(segNum))
+			delete(w.segmentMaxSeq, segNum)
+		}
+	}
+}
+
+func (w *wal[T]) openSegmentForWrite() error {
+	f, err := os.OpenFile(w.segmentFileName(w.segmentNum), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.writeFile = f
+	return nil
+}
+
+func (w *wal[T]) rotate() error {
+	if w.writeFile != nil {
+		_ = w.writeFile.Sync()
+		if err := w.writeFile.Close(); err != nil {
+			return err
+		}
+	}
+	w.segmentNum++
+	w.segmentPos = 0
+	return w.openSegmentForWrite()
+}
+
+func (w *wal[T]) encode(row T) ([]byte, error) {
+	if w.codec != nil {
+		var buf bytes.Buffer
+		if err := w.codec.Encode(&buf, []T{row}, Meta{Time: time.Now().Format(time.RFC3339), Count: 1}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(row)
+}
+
+func (w *wal[T]) decode(body []byte) ([]T, error) {
+	if w.codec != nil {
+		rows, _, err := w.codec.Decode(bytes.NewReader(body))
+		return rows, err
+	}
+	var row T
+	if err := json.Unmarshal(body, &row); err != nil {
+		return nil, err
+	}
+	return []T{row}, nil
+}
+
+// append 写入一条记录，返回分配给它的递增序列号
+func (w *wal[T]) append(row T) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	body, err := w.encode(row)
+	if err != nil {
+		return 0, err
+	}
+
+	seq := w.nextSeq
+	w.nextSeq++
+
+	var hdr [12]byte
+	binary.BigEndian.PutUint64(hdr[0:8], seq)
+	binary.BigEndian.PutUint32(hdr[8:12], uint32(len(body)))
+
+	if _, err = w.writeFile.Write(hdr[:]); err != nil {
+		return seq, err
+	}
+	if _, err = w.writeFile.Write(body); err != nil {
+		return seq, err
+	}
+
+	w.segmentPos += int64(len(hdr)) + int64(len(body))
+	w.segmentMaxSeq[w.segmentNum] = seq
+	w.writesSinceSync++
+
+	if w.segmentPos >= w.maxSegmentBytes {
+		if err = w.rotate(); err != nil {
+			return seq, err
+		}
+	}
+
+	if w.writesSinceSync >= w.syncEvery {
+		if err = w.writeFile.Sync(); err != nil {
+			return seq, err
+		}
+		w.writesSinceSync = 0
+	}
+
+	return seq, nil
+}
+
+// checkpoint 推进已确认消费的序列号，并清理被完全覆盖的历史分段
+func (w *wal[T]) checkpoint(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if seq <= w.checkpointSeq {
+		return nil
+	}
+	w.checkpointSeq = seq
+
+	if err := w.persistCheckpoint(); err != nil {
+		return err
+	}
+
+	w.cleanupCoveredSegments()
+	return nil
+}
+
+func (w *wal[T]) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.writeFile == nil {
+		return nil
+	}
+	_ = w.writeFile.Sync()
+	err := w.writeFile.Close()
+	w.writeFile = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package midstore
+
+import (
+	"os"
+	"testing"
+)
+
+// TestWALRecoversUncheckpointedEntries 模拟进程在 FlushCall 确认之前崩溃：
+// append 几条记录后直接关闭（不调用 checkpoint），重新打开 WAL 应该能把它们原样重放出来。
+func TestWALRecoversUncheckpointedEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	w, recovered, err := newWAL[elem](dir, 1, 1024*1024, nil, nil)
+	if err != nil {
+		t.Fatalf("newWAL error: %v", err)
+	}
+	if len(recovered) != 0 {
+		t.Fatalf("expected no recovered rows on a fresh WAL, got %d", len(recovered))
+	}
+
+	rows := []elem{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}, {Id: 3, Name: "c"}}
+	for _, row := range rows {
+		if _, err := w.append(row); err != nil {
+			t.Fatalf("append error: %v", err)
+		}
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	w2, recovered2, err := newWAL[elem](dir, 1, 1024*1024, nil, nil)
+	if err != nil {
+		t.Fatalf("re-open newWAL error: %v", err)
+	}
+	defer w2.close()
+
+	if len(recovered2) != len(rows) {
+		t.Fatalf("expected %d recovered rows, got %d", len(rows), len(recovered2))
+	}
+	for i, row := range rows {
+		if recovered2[i] != row {
+			t.Fatalf("recovered row %d mismatch: want %+v, got %+v", i, row, recovered2[i])
+		}
+	}
+}
+
+// TestWALCheckpointSkipsConfirmedEntries 验证 checkpoint 之后的记录不会被当成
+// 未确认数据重放，且 checkpoint 文件被落盘（对应 #chunk0-4 review fix：
+// checkpoint 只应该在数据被确认 durable 之后推进）。
+func TestWALCheckpointSkipsConfirmedEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	w, _, err := newWAL[elem](dir, 1, 1024*1024, nil, nil)
+	if err != nil {
+		t.Fatalf("newWAL error: %v", err)
+	}
+
+	var lastSeq uint64
+	for i := 1; i <= 3; i++ {
+		seq, err := w.append(elem{Id: i, Name: "x"})
+		if err != nil {
+			t.Fatalf("append error: %v", err)
+		}
+		lastSeq = seq
+	}
+
+	if err := w.checkpoint(lastSeq); err != nil {
+		t.Fatalf("checkpoint error: %v", err)
+	}
+
+	// 再写一条未确认的记录
+	if _, err := w.append(elem{Id: 4, Name: "y"}); err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	w2, recovered, err := newWAL[elem](dir, 1, 1024*1024, nil, nil)
+	if err != nil {
+		t.Fatalf("re-open newWAL error: %v", err)
+	}
+	defer w2.close()
+
+	if len(recovered) != 1 || recovered[0].Id != 4 {
+		t.Fatalf("expected only the unconfirmed row to be replayed, got %+v", recovered)
+	}
+
+	if _, err := os.Stat(w.checkpointFileName()); err != nil {
+		t.Fatalf("expected checkpoint file to exist: %v", err)
+	}
+}
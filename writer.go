@@ -1,6 +1,7 @@
 package midstore
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,7 +14,9 @@ type defaultWriter struct {
 	fileName string
 }
 
-func (w *defaultWriter) GetWriter() (*os.File, error) {
+var _ IWriter = &defaultWriter{}
+
+func (w *defaultWriter) getFile() (*os.File, error) {
 	filename := filepath.Join(w.opt.failedFileDir, fmt.Sprintf("%s.%s.log", w.opt.failedFileName, time.Now().Format("20060102")))
 	if w.curFile != nil && w.fileName == filename {
 		return w.curFile, nil
@@ -38,7 +41,29 @@ func (w *defaultWriter) GetWriter() (*os.File, error) {
 	return file, nil
 }
 
-func (w *defaultWriter) OnWriteFailed(data []byte) {
+// Write 按天滚动追加一行数据，行尾自动补 \n
+func (w *defaultWriter) Write(data []byte) error {
+	file, err := w.getFile()
+	if err != nil {
+		w.onWriteFailed(data)
+		return err
+	}
+
+	bw := bufio.NewWriter(file)
+	if _, err = bw.Write(data); err != nil {
+		w.onWriteFailed(data)
+		return err
+	}
+	_, _ = bw.Write([]byte("\n"))
+
+	if err = bw.Flush(); err != nil {
+		w.onWriteFailed(data)
+		return err
+	}
+	return nil
+}
+
+func (w *defaultWriter) onWriteFailed(data []byte) {
 	w.opt.log.Warnf("write failed file error,data: %s", string(data))
 }
 